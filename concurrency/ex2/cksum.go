@@ -2,34 +2,65 @@
 package main
 
 import (
-	"crypto/sha1"
+	"context"
+	"encoding/binary"
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"os"
-	"path/filepath"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+	"github.com/ip1996/golang-elte-2020-public/concurrency/mhash"
 )
 
-//Hashes stores information about the hashed files
+var hashFlag = flag.String("hash", "sha1", "comma-separated hash algorithms to compute in one pass: "+
+	"sha1, sha256, sha512, blake2b, blake3, md5, crc32, cksum (the POSIX cksum(1) CRC32)")
+
+// algos is the parsed, validated form of *hashFlag, set once in main.
+var algos []string
+
+// Hashes stores information about the hashed files
 type Hashes struct {
 	path string
-	hash []byte
+	sums map[string][]byte
+	size int64
 	err  error
 }
 
 func main() {
-	// TODO: parallelize the checksum calculation
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		runVerify(os.Args[2:])
+		return
+	}
+
+	flag.Parse()
+	var err error
+	algos, err = mhash.Parse(*hashFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *treeMode {
+		runTree(flag.Args())
+		return
+	}
+	if *formatFlag == "mtree" {
+		runMtree(flag.Args())
+		return
+	}
+
 	hashesQueu := make(chan Hashes, 100)
 	guard := make(chan struct{}, 100)
 	files := Files()
 	filesLen := len(files)
-	for _, path := range files {
+	for _, target := range files {
 		guard <- struct{}{}
-		go func(p string) {
-			hash, err := Hash(p)
-			hashesQueu <- Hashes{path: p, hash: hash, err: err}
+		go func(t fileTarget) {
+			sums, size, err := Hash(t.fsys, t.path)
+			hashesQueu <- Hashes{path: t.path, sums: sums, size: size, err: err}
 			<-guard
-		}(path)
+		}(target)
 	}
 
 	for i := 0; i < filesLen; i++ {
@@ -38,35 +69,72 @@ func main() {
 			fmt.Printf("ERROR: %s\n", evHash.err)
 			continue
 		}
-		fmt.Printf("%x\t%s\n", evHash.hash, evHash.path)
+		printResult(evHash)
 	}
-	// END OMIT
 }
 
-// Hash calculates a checksum of a file.
+// printResult prints a hash result as one "<algo>:<hex>\t<path>" line per
+// requested algorithm, so downstream tools can parse each digest
+// unambiguously. The single exception is -hash=cksum on its own, which
+// prints "<crc> <size> <path>" to stay interchangeable with GNU/BSD cksum.
+func printResult(r Hashes) {
+	if len(algos) == 1 && algos[0] == "cksum" {
+		fmt.Printf("%d %d %s\n", binary.BigEndian.Uint32(r.sums["cksum"]), r.size, r.path)
+		return
+	}
+	for _, name := range algos {
+		fmt.Printf("%s:%x\t%s\n", name, r.sums[name], r.path)
+	}
+}
+
+// Hash calculates the digest of a file read through fsys for every
+// algorithm in algos, reading the file exactly once.
 // It returns an error, if the file was not readable.
-func Hash(path string) ([]byte, error) {
-	f, err := os.Open(path)
+func Hash(fsys fs.Filesystem, path string) (map[string][]byte, int64, error) {
+	f, err := fsys.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer f.Close()
 
-	h := sha1.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return nil, err
+	counting := &countingReader{r: f}
+	sums, err := mhash.Sum(counting, algos)
+	if err != nil {
+		return nil, 0, err
 	}
-	return h.Sum(nil), nil
+	return sums, counting.n, nil
+}
+
+// countingReader wraps a reader to also track how many bytes were read,
+// since mhash.Sum only returns the digests.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// fileTarget pairs a path with the filesystem it should be read through, so
+// a single run of cksum can mix local files, archives and remote shares.
+type fileTarget struct {
+	fsys fs.Filesystem
+	path string
 }
 
-// Files returns the list of file paths that are expanded from walking the tree
-// of every command line arguments.
-func Files() []string {
-	var files []string
+// Files returns the list of file targets that are expanded from walking the
+// tree of every command line argument, each resolved to its own Filesystem
+// (local disk, tar/zip archive, or WebDAV share).
+func Files() []fileTarget {
+	var files []fileTarget
 	flag.Parse()
-	for _, path := range flag.Args() {
+	for _, arg := range flag.Args() {
+		fsys, root := fs.Resolve(arg)
 		// Walk will return no error, because all WalkFunc always returns nil.
-		filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		fsys.Walk(context.Background(), root, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				fmt.Printf("ERROR: unable to access %q\n", path)
 				return nil
@@ -74,7 +142,7 @@ func Files() []string {
 			if info.Mode()&os.ModeType != 0 {
 				return nil // Not a regular file.
 			}
-			files = append(files, path)
+			files = append(files, fileTarget{fsys: fsys, path: path})
 			return nil
 		})
 	}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+	"github.com/ip1996/golang-elte-2020-public/concurrency/merkle"
+)
+
+var (
+	treeMode  = flag.Bool("tree", false, "emit a single Merkle digest per directory argument instead of hashing files individually")
+	cachePath = flag.String("tree-cache", ".cksum-tree-cache", "file used to cache directory digests between --tree runs")
+)
+
+// runTree computes and prints a Merkle digest for every directory argument,
+// reusing unchanged files' digests from the on-disk cache.
+func runTree(args []string) {
+	cache := merkle.LoadCache(*cachePath)
+	for _, arg := range args {
+		fsys, root := fs.Resolve(arg)
+		header, content, err := merkle.Digest(context.Background(), fsys, root, cache)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			continue
+		}
+		fmt.Printf("%x (header %x)\t%s\n", content, header, root)
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Printf("ERROR: unable to persist tree cache: %s\n", err)
+	}
+}
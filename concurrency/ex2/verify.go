@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+)
+
+// runVerify implements the "verify" subcommand: it reads an mtree(5)
+// manifest produced by -format=mtree and reports every entry whose size or
+// sha256 digest no longer matches the file on disk.
+func runVerify(args []string) {
+	if len(args) < 1 {
+		fmt.Println("usage: cksum verify <manifest> [root]")
+		os.Exit(2)
+	}
+	manifest := args[0]
+	root := "."
+	if len(args) > 1 {
+		root = args[1]
+	}
+
+	f, err := os.Open(manifest)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	mismatches := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "/set") {
+			continue
+		}
+		entry, err := parseMtreeLine(line)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			continue
+		}
+
+		path := entry.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		sum, size, err := sha256Digest(fs.Local{}, path)
+		switch {
+		case os.IsNotExist(err):
+			fmt.Printf("%s: missing\n", entry.path)
+			mismatches++
+		case err != nil:
+			fmt.Printf("%s: ERROR: %s\n", entry.path, err)
+			mismatches++
+		case size != entry.size || fmt.Sprintf("%x", sum) != entry.sha256:
+			fmt.Printf("%s: changed\n", entry.path)
+			mismatches++
+		}
+	}
+	if mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// mtreeEntry is the subset of an mtree(5) line that verify cares about.
+type mtreeEntry struct {
+	path   string
+	size   int64
+	sha256 string
+}
+
+func parseMtreeLine(line string) (mtreeEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return mtreeEntry{}, fmt.Errorf("empty manifest line")
+	}
+	entry := mtreeEntry{path: fields[0]}
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "size":
+			size, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return mtreeEntry{}, fmt.Errorf("bad size in %q: %v", line, err)
+			}
+			entry.size = size
+		case "sha256digest":
+			entry.sha256 = parts[1]
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+)
+
+// pathItem is a path paired with the Filesystem it was found through, so a
+// scan that mixes local, archive and WebDAV targets can still tell them
+// apart once their paths are interleaved on a single channel.
+type pathItem struct {
+	fsys fs.Filesystem
+	path string
+}
+
+// Walker streams the regular files beneath a set of targets into fn instead
+// of materializing them into a slice first, checking ctx between every
+// entry so a scan can be cancelled mid-walk rather than run to completion
+// regardless.
+type Walker struct{}
+
+// Walk walks every target in turn, calling fn once per regular file. It
+// stops as soon as ctx is done, returning ctx.Err().
+func (Walker) Walk(ctx context.Context, targets []target, fn func(fsys fs.Filesystem, path string) error) error {
+	for _, t := range targets {
+		err := t.fsys.Walk(ctx, t.root, func(path string, info os.FileInfo, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				return nil
+			}
+			if info.Mode()&os.ModeType != 0 {
+				return nil // Not a regular file.
+			}
+			return fn(t.fsys, path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HashPool hashes paths pulled off a channel using a bounded number of
+// concurrent workers.
+type HashPool struct {
+	Concurrency int
+}
+
+// Run starts the pool's workers and returns the channel they publish
+// results to. The channel is closed once paths has drained and every
+// worker has returned, which also happens as soon as ctx is done.
+func (p HashPool) Run(ctx context.Context, paths <-chan pathItem) <-chan *Hashed {
+	n := p.Concurrency
+	if n <= 0 {
+		n = 100
+	}
+
+	results := make(chan *Hashed)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case item, ok := <-paths:
+					if !ok {
+						return
+					}
+					select {
+					case results <- Hash(item.fsys, item.path):
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+)
+
+// infiniteFS is a Filesystem whose Walk never terminates on its own: every
+// root has an endless stream of synthetic children below it. It exists only
+// to prove that HashAll actually stops once its context is cancelled,
+// instead of running to completion (or piling up goroutines) on a
+// pathological tree.
+type infiniteFS struct{}
+
+func (infiniteFS) Open(path string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader("x")), nil
+}
+
+func (infiniteFS) Lstat(path string) (os.FileInfo, error) {
+	return infiniteFileInfo(path), nil
+}
+
+func (infiniteFS) Walk(ctx context.Context, root string, fn fs.WalkFunc) error {
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := fmt.Sprintf("%s/%d", root, i)
+		if err := fn(path, infiniteFileInfo(path), nil); err != nil {
+			return err
+		}
+	}
+}
+
+type infiniteFileInfo string
+
+func (i infiniteFileInfo) Name() string       { return string(i) }
+func (i infiniteFileInfo) Size() int64        { return 1 }
+func (i infiniteFileInfo) Mode() os.FileMode  { return 0644 }
+func (i infiniteFileInfo) ModTime() time.Time { return time.Time{} }
+func (i infiniteFileInfo) IsDir() bool        { return false }
+func (i infiniteFileInfo) Sys() interface{}   { return nil }
+
+func TestHashAllStopsOnCancel(t *testing.T) {
+	algos = []string{"sha1"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		HashAll(ctx, []target{{fsys: infiniteFS{}, root: "/inf"}})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("HashAll did not stop after its context was cancelled")
+	}
+}
@@ -2,41 +2,453 @@
 package main
 
 import (
-	"crypto/sha1"
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
-	"io"
+	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+	"github.com/ip1996/golang-elte-2020-public/concurrency/merkle"
+	"github.com/ip1996/golang-elte-2020-public/concurrency/mhash"
 )
 
-// The main function is the entrypoint for our program, which is checking for modifications on the given fileset in every second.
-// If there is a modification it will be printed to the console.
+// debounceWindow coalesces bursts of WRITE events on the same path (editors
+// routinely emit several writes for a single save) into a single re-hash.
+const debounceWindow = 250 * time.Millisecond
+
+var poll = flag.Bool("poll", false, "poll every second instead of using fsnotify (use on network mounts)")
+var hashFlag = flag.String("hash", "sha1", "comma-separated hash algorithms to compute for every file (see mhash.Names)")
+
+// algos is the parsed, validated form of *hashFlag, set once in main.
+var algos []string
+
+// The main function is the entrypoint for our program, which is checking for modifications on the given fileset.
+// Each argument is resolved to a Filesystem (local disk, tar/zip archive or WebDAV share) via fs.Resolve. When every
+// argument is on the local disk and -poll wasn't given, changes are reacted to as they happen; otherwise the fileset
+// is re-scanned through the Filesystem abstraction once a second.
 func main() {
-	prev := HashAll()
-	for ts := range time.Tick(time.Second) {
-		curr := HashAll()
-		added, edited, deleted := CompareFileSets(prev, curr)
-		prev = curr
-		if len(added)+len(edited)+len(deleted) > 0 {
-			fmt.Printf("files have changed at %v\n", ts)
-			fmt.Printf("\tadded: %q\n", added)
-			fmt.Printf("\tedited: %q\n", edited)
-			fmt.Printf("\tdeleted: %q\n", deleted)
+	flag.Parse()
+	var err error
+	algos, err = mhash.Parse(*hashFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	targets := resolveTargets(flag.Args())
+
+	if !*poll && allLocal(targets) {
+		roots := make([]string, len(targets))
+		for i, t := range targets {
+			roots[i] = t.root
+		}
+		if err := watchEvents(roots); err != nil {
+			log.Printf("fsnotify unavailable (%v), falling back to polling", err)
+			watchPoll(targets)
+		}
+		return
+	}
+	watchPoll(targets)
+}
+
+// target pairs a walk root with the Filesystem it should be read through.
+type target struct {
+	fsys fs.Filesystem
+	root string
+}
+
+// resolveTargets resolves every command line argument to its Filesystem.
+func resolveTargets(args []string) []target {
+	targets := make([]target, len(args))
+	for i, arg := range args {
+		fsys, root := fs.Resolve(arg)
+		targets[i] = target{fsys: fsys, root: root}
+	}
+	return targets
+}
+
+// allLocal reports whether every target is backed by the local disk, which
+// is the only Filesystem fsnotify can subscribe to directly.
+func allLocal(targets []target) bool {
+	for _, t := range targets {
+		if _, ok := t.fsys.(fs.Local); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// watchPoll re-hashes every file under targets once a second, through
+// whichever Filesystem each one was resolved to, and reports the
+// difference against the previous scan. A scan that is still running when
+// the next tick fires is cancelled and restarted, instead of piling up
+// alongside it; SIGINT cancels whatever scan is in flight and stops the loop.
+//
+// Before re-hashing individual files, each target's Merkle content digest
+// (see concurrency/merkle) is recomputed; a target whose digest hasn't
+// moved since the previous tick is skipped entirely instead of diffing it
+// file by file, so an unchanged tree costs a walk that reuses cached file
+// digests plus one digest comparison per target, rather than re-reading and
+// re-hashing every file in it.
+func watchPoll(targets []target) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	treeCache := merkle.NewCache()
+	prev := HashAll(ctx, targets)
+	prevDigests := digestTargets(ctx, targets, treeCache)
+
+	type scanResult struct {
+		ts      time.Time
+		curr    FileSet
+		digests map[string][]byte
+	}
+	results := make(chan scanResult, 1)
+
+	var cancelScan context.CancelFunc
+	scanning := false
+	startScan := func(ts time.Time) {
+		var scanCtx context.Context
+		scanCtx, cancelScan = context.WithCancel(ctx)
+		scanning = true
+		go func() {
+			digests := digestTargets(scanCtx, targets, treeCache)
+			changed := changedTargets(targets, prevDigests, digests)
+
+			curr := prev
+			if len(changed) > 0 {
+				curr = mergeFileSets(prev, HashAll(scanCtx, changed), changed)
+			}
+			if scanCtx.Err() == nil {
+				results <- scanResult{ts: ts, curr: curr, digests: digests}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if cancelScan != nil {
+				cancelScan()
+			}
+			return
+		case ts := <-ticker.C:
+			if scanning {
+				cancelScan() // previous scan is still running; drop its result.
+			}
+			startScan(ts)
+		case res := <-results:
+			scanning = false
+			added, edited, deleted := CompareFileSets(prev, res.curr)
+			prev = res.curr
+			prevDigests = res.digests
+			report(res.ts, added, edited, deleted)
 		}
 	}
 }
 
-// Hashed is a struct for storing the file path and hash value or the error if hashing fails.
+// digestTargets computes each target's Merkle content digest, keyed by its
+// root. A target whose digest can't be computed (e.g. a transient read
+// error) is left out of the map so changedTargets always treats it as
+// changed rather than silently skipping it forever.
+func digestTargets(ctx context.Context, targets []target, cache *merkle.Cache) map[string][]byte {
+	digests := make(map[string][]byte, len(targets))
+	for _, t := range targets {
+		if _, content, err := merkle.Digest(ctx, t.fsys, t.root, cache); err == nil {
+			digests[t.root] = content
+		}
+	}
+	return digests
+}
+
+// changedTargets returns the subset of targets whose digest moved between
+// prev and curr, or that are missing a digest in either (which forces a
+// re-hash rather than risking a false "unchanged").
+func changedTargets(targets []target, prev, curr map[string][]byte) []target {
+	var changed []target
+	for _, t := range targets {
+		p, pok := prev[t.root]
+		c, cok := curr[t.root]
+		if !pok || !cok || !bytes.Equal(p, c) {
+			changed = append(changed, t)
+		}
+	}
+	return changed
+}
+
+// mergeFileSets returns prev with the entries under every changed target's
+// root replaced by curr, leaving every other target's entries untouched.
+func mergeFileSets(prev, curr FileSet, changed []target) FileSet {
+	roots := make([]string, len(changed))
+	for i, t := range changed {
+		roots[i] = t.root
+	}
+
+	merged := make(FileSet, len(prev)+len(curr))
+	for path, h := range prev {
+		if !underAnyRoot(path, roots) {
+			merged[path] = h
+		}
+	}
+	for path, h := range curr {
+		merged[path] = h
+	}
+	return merged
+}
+
+// watchEvents subscribes to CREATE/WRITE/RENAME/REMOVE events on every
+// directory under roots and only re-hashes the paths that actually changed,
+// instead of re-walking the whole tree on a tick. Subtrees that cannot be
+// watched (e.g. because the inotify watch-descriptor limit was hit) are
+// degraded to polling. It only supports local disk roots.
+func watchEvents(roots []string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	ew := &eventWatcher{
+		watcher: w,
+		current: make(FileSet),
+		timers:  make(map[string]*time.Timer),
+	}
+
+	for _, root := range roots {
+		if err := ew.addTree(root, true); err != nil {
+			return err
+		}
+	}
+
+	go ew.pollDegraded()
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			ew.handle(event)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("watch error: %v", err)
+		}
+	}
+}
+
+// eventWatcher holds the live state for event-driven watching: the
+// fsnotify subscription, the last known hash of every path, pending
+// debounce timers and the set of subtrees that had to be degraded to
+// polling because they exceeded the watch-descriptor limit.
+type eventWatcher struct {
+	watcher *fsnotify.Watcher
+
+	mu       sync.Mutex
+	current  FileSet
+	timers   map[string]*time.Timer
+	degraded []string
+}
+
+// addTree registers watches for dir and every directory beneath it, hashing
+// every regular file it finds along the way. If the inotify watch-descriptor
+// limit is hit for a subtree, that subtree is logged and handed off to
+// pollDegraded instead of failing the whole walk.
+//
+// seed distinguishes the initial startup walk (seed=true: establish the
+// baseline silently) from a directory that appears mid-run (seed=false:
+// every file found is genuinely new and must be reported as added) - e.g.
+// moving or extracting a populated directory into the watched tree.
+func (ew *eventWatcher) addTree(dir string, seed bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Printf("ERROR: unable to access %q\n", path)
+			return nil
+		}
+		if !info.IsDir() {
+			if seed {
+				ew.seedPath(path)
+			} else {
+				ew.hashPath(path)
+			}
+			return nil
+		}
+		if err := ew.watcher.Add(path); err != nil {
+			if err == syscall.ENOSPC {
+				log.Printf("watch-descriptor limit reached, degrading %q to polling", path)
+				ew.mu.Lock()
+				ew.degraded = append(ew.degraded, path)
+				ew.mu.Unlock()
+				return filepath.SkipDir
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// handle reacts to a single fsnotify event, debouncing rapid bursts on the
+// same path into a single re-hash.
+func (ew *eventWatcher) handle(event fsnotify.Event) {
+	switch {
+	case event.Op&fsnotify.Create != 0:
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := ew.addTree(event.Name, false); err != nil {
+				log.Printf("failed to watch new directory %q: %v", event.Name, err)
+			}
+			return
+		}
+		ew.debounce(event.Name)
+	case event.Op&(fsnotify.Write|fsnotify.Rename) != 0:
+		ew.debounce(event.Name)
+	case event.Op&fsnotify.Remove != 0:
+		ew.remove(event.Name)
+	}
+}
+
+// debounce schedules a re-hash of path after debounceWindow, resetting the
+// timer if one is already pending so a burst of events collapses into a
+// single comparison.
+func (ew *eventWatcher) debounce(path string) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	if t, ok := ew.timers[path]; ok {
+		t.Reset(debounceWindow)
+		return
+	}
+	ew.timers[path] = time.AfterFunc(debounceWindow, func() {
+		ew.mu.Lock()
+		delete(ew.timers, path)
+		ew.mu.Unlock()
+		ew.hashPath(path)
+	})
+}
+
+// seedPath hashes a single local path into the known file set without
+// reporting a change, establishing the starting baseline during the initial
+// walk so watch doesn't print an "added" banner for every pre-existing file.
+func (ew *eventWatcher) seedPath(path string) {
+	h := Hash(fs.Local{}, path)
+
+	ew.mu.Lock()
+	ew.current[path] = h
+	ew.mu.Unlock()
+}
+
+// hashPath re-hashes a single local path and reports it as added or edited
+// if its hash changed since the last observation. A path that no longer
+// exists is routed to remove instead of being hashed: a rename out of a
+// watched tree surfaces as a Rename event on the old name with no matching
+// Remove (inotify's IN_MOVED_FROM), so without this check it would be
+// mis-reported as "edited" and leave a permanently broken entry behind.
+func (ew *eventWatcher) hashPath(path string) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		ew.remove(path)
+		return
+	}
+
+	h := Hash(fs.Local{}, path)
+
+	ew.mu.Lock()
+	before, existed := ew.current[path]
+	ew.current[path] = h
+	ew.mu.Unlock()
+
+	ts := time.Now()
+	switch {
+	case !existed:
+		report(ts, []string{path}, nil, nil)
+	case !HashedEqual(before, h):
+		report(ts, nil, []string{path}, nil)
+	}
+}
+
+// remove drops path from the known file set and reports it as deleted.
+func (ew *eventWatcher) remove(path string) {
+	ew.mu.Lock()
+	_, existed := ew.current[path]
+	delete(ew.current, path)
+	ew.mu.Unlock()
+
+	if existed {
+		report(time.Now(), nil, nil, []string{path})
+	}
+}
+
+// pollDegraded re-scans the subtrees that fell back to polling (because they
+// exceeded the inotify watch-descriptor limit) once a second, reusing the
+// same incremental hashPath/remove reporting as the event-driven path.
+func (ew *eventWatcher) pollDegraded() {
+	for range time.Tick(time.Second) {
+		ew.mu.Lock()
+		roots := append([]string(nil), ew.degraded...)
+		ew.mu.Unlock()
+		if len(roots) == 0 {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, root := range roots {
+			for _, path := range Files(fs.Local{}, root) {
+				seen[path] = true
+				ew.hashPath(path)
+			}
+		}
+
+		ew.mu.Lock()
+		var stale []string
+		for path := range ew.current {
+			if !seen[path] && underAnyRoot(path, roots) {
+				stale = append(stale, path)
+			}
+		}
+		ew.mu.Unlock()
+		for _, path := range stale {
+			ew.remove(path)
+		}
+	}
+}
+
+// underAnyRoot reports whether path lies within one of the given roots.
+func underAnyRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
+
+func report(ts time.Time, added, edited, deleted []string) {
+	if len(added)+len(edited)+len(deleted) > 0 {
+		fmt.Printf("files have changed at %v\n", ts)
+		fmt.Printf("\tadded: %q\n", added)
+		fmt.Printf("\tedited: %q\n", edited)
+		fmt.Printf("\tdeleted: %q\n", deleted)
+	}
+}
+
+// Hashed is a struct for storing the file path and its multi-algorithm hash
+// (one digest per name in algos), or the error if hashing fails.
 type Hashed struct {
 	Path string
-	Hash []byte
+	Hash map[string][]byte
 	Err  error // Hash is invalid, in case of an error
 }
 
-// HashedEqual compares two hashed values.
+// HashedEqual compares two hashed values across every algorithm they carry.
 func HashedEqual(before, after *Hashed) bool {
 	if before == nil || after == nil {
 		return before == nil && after == nil
@@ -47,15 +459,7 @@ func HashedEqual(before, after *Hashed) bool {
 	if be, ae := before.Err != nil, after.Err != nil; be || ae {
 		return be == ae
 	}
-	if len(before.Hash) != len(after.Hash) {
-		return false
-	}
-	for i := 0; i < len(before.Hash); i++ {
-		if before.Hash[i] != after.Hash[i] {
-			return false
-		}
-	}
-	return true
+	return mhash.Equal(before.Hash, after.Hash)
 }
 
 // FileSet is a mapping between pathes and their hashed values.
@@ -79,66 +483,61 @@ func CompareFileSets(before, after FileSet) (added, edited, deleted []string) {
 	return added, edited, deleted
 }
 
-// HashAll fills up a shared map during goroutines with pathes and their hashed values of the filesystem
-// or error if hashing fails.
-// The maximum number of launchable goroutines is limited to 100.
-func HashAll() FileSet {
-	// TODO: max 100 concurrent I/O
+// HashAll walks every target and hashes the files it finds through a bounded
+// pool of 100 workers, stopping as soon as ctx is done instead of letting an
+// in-flight scan of a huge (or pathological) tree pile up in the background.
+func HashAll(ctx context.Context, targets []target) FileSet {
+	paths := make(chan pathItem)
+	go func() {
+		defer close(paths)
+		Walker{}.Walk(ctx, targets, func(fsys fs.Filesystem, path string) error {
+			select {
+			case paths <- pathItem{fsys: fsys, path: path}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
 	results := make(FileSet)
-	mu := sync.Mutex{}
-	wg := sync.WaitGroup{}
-	sem := make(chan struct{}, 100)
-	for _, path := range Files() {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(path string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			hash := Hash(path)
-			mu.Lock()
-			defer mu.Unlock()
-			results[path] = hash
-		}(path)
-	}
-	wg.Wait()
-	// END OMIT
+	for h := range (HashPool{Concurrency: 100}).Run(ctx, paths) {
+		results[h.Path] = h
+	}
 	return results
 }
 
-// Hash calculates a checksum of a file.
-// It returns an error, if the file was not readable.
-func Hash(path string) *Hashed {
-	f, err := os.Open(path)
+// Hash calculates the digest of a file read through fsys for every
+// algorithm in algos. It returns an error, if the file was not readable.
+func Hash(fsys fs.Filesystem, path string) *Hashed {
+	f, err := fsys.Open(path)
 	if err != nil {
 		return &Hashed{Path: path, Err: err}
 	}
 	defer f.Close()
 
-	h := sha1.New()
-	if _, err := io.Copy(h, f); err != nil {
+	sums, err := mhash.Sum(f, algos)
+	if err != nil {
 		return &Hashed{Path: path, Err: err}
 	}
-	return &Hashed{Path: path, Hash: h.Sum(nil)}
+	return &Hashed{Path: path, Hash: sums}
 }
 
-// Files returns the list of file paths that are expanded from walking the tree
-// of every command line arguments.
-func Files() []string {
+// Files returns the list of file paths that are expanded from walking root through fsys.
+func Files(fsys fs.Filesystem, root string) []string {
 	var files []string
-	flag.Parse()
-	for _, path := range flag.Args() {
-		// Walk will return no error, because all WalkFunc always returns nil.
-		filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				fmt.Printf("ERROR: unable to access %q\n", path)
-				return nil
-			}
-			if info.Mode()&os.ModeType != 0 {
-				return nil // Not a regular file.
-			}
-			files = append(files, path)
+	// The WalkFunc always returns nil, so Walk only fails when fsys cannot
+	// start the walk (e.g. an unreadable archive); that error is ignored.
+	fsys.Walk(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Printf("ERROR: unable to access %q\n", path)
 			return nil
-		})
-	}
+		}
+		if info.Mode()&os.ModeType != 0 {
+			return nil // Not a regular file.
+		}
+		files = append(files, path)
+		return nil
+	})
 	return files
 }
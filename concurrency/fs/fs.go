@@ -0,0 +1,31 @@
+// Package fs provides a small filesystem abstraction so that tools like
+// cksum and watch can walk and read files uniformly whether they live on
+// local disk, inside a tar/zip archive, or on a WebDAV share.
+package fs
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// WalkFunc is called once per entry encountered by Walk, mirroring
+// filepath.WalkFunc. Returning an error from the callback (other than
+// filepath.SkipDir) aborts the walk and is propagated to the caller. Only
+// Local honours filepath.SkipDir; Tar, Zip and WebDAV treat it like any
+// other error.
+type WalkFunc func(path string, info os.FileInfo, err error) error
+
+// Filesystem abstracts the operations cksum and watch need: opening a
+// regular file for reading, stat-ing an entry without following a trailing
+// symlink, and recursively walking a tree.
+type Filesystem interface {
+	// Open returns a reader for the regular file at path. The caller must
+	// close it.
+	Open(path string) (io.ReadCloser, error)
+	// Lstat returns file info for path without following a trailing symlink.
+	Lstat(path string) (os.FileInfo, error)
+	// Walk walks the tree rooted at root, calling fn for every entry it
+	// finds. It stops early and returns ctx.Err() as soon as ctx is done.
+	Walk(ctx context.Context, root string, fn WalkFunc) error
+}
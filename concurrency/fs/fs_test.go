@@ -0,0 +1,333 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// collect runs fsys.Walk(root) and returns the paths it visited, in the
+// order Walk produced them.
+func collect(t *testing.T, fsys Filesystem, root string) []string {
+	t.Helper()
+	var paths []string
+	err := fsys.Walk(context.Background(), root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			t.Fatalf("Walk(%q): %v", path, err)
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	return paths
+}
+
+func readAll(t *testing.T, fsys Filesystem, path string) string {
+	t.Helper()
+	rc, err := fsys.Open(path)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", path, err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %q: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := Local{}
+
+	if got := readAll(t, fsys, filepath.Join(dir, "a.txt")); got != "hello" {
+		t.Errorf("Open(a.txt) = %q, want %q", got, "hello")
+	}
+
+	info, err := fsys.Lstat(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("Lstat(a.txt).Size() = %d, want 5", info.Size())
+	}
+
+	paths := collect(t, fsys, dir)
+	want := []string{dir, filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub"), filepath.Join(dir, "sub", "b.txt")}
+	sort.Strings(paths)
+	sort.Strings(want)
+	if len(paths) != len(want) {
+		t.Fatalf("Walk visited %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("Walk visited %v, want %v", paths, want)
+			break
+		}
+	}
+}
+
+func TestLocalWalkCancelled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Local{}.Walk(ctx, dir, func(path string, info os.FileInfo, err error) error {
+		return err
+	})
+	if err == nil {
+		t.Fatal("Walk with an already-cancelled context returned nil error")
+	}
+}
+
+func writeTestTar(t *testing.T, path string, gzipped bool) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var tw *tar.Writer
+	if gzipped {
+		gw := gzip.NewWriter(f)
+		defer gw.Close()
+		tw = tar.NewWriter(gw)
+	} else {
+		tw = tar.NewWriter(f)
+	}
+	defer tw.Close()
+
+	for _, e := range []struct {
+		name, content string
+	}{
+		{"a.txt", "hello"},
+		{"sub/b.txt", "world"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Size: int64(len(e.content)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestTarRootDot mirrors what fs.Resolve hands every Tar target: a walk
+// rooted at "." must visit every entry by its raw header name, with no
+// "./" prefix, since that's what Open/Lstat match against.
+func TestTarRootDot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	writeTestTar(t, path, false)
+
+	fsys := Tar{ArchivePath: path}
+
+	if got := readAll(t, fsys, "a.txt"); got != "hello" {
+		t.Errorf("Open(a.txt) = %q, want %q", got, "hello")
+	}
+	if got := readAll(t, fsys, "sub/b.txt"); got != "world" {
+		t.Errorf("Open(sub/b.txt) = %q, want %q", got, "world")
+	}
+
+	info, err := fsys.Lstat("a.txt")
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("Lstat(a.txt).Size() = %d, want 5", info.Size())
+	}
+
+	paths := collect(t, fsys, ".")
+	sort.Strings(paths)
+	want := []string{"a.txt", "sub/b.txt"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("Walk(%q) visited %v, want %v", ".", paths, want)
+	}
+}
+
+func TestTarGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.tar.gz")
+	writeTestTar(t, path, true)
+
+	fsys := Tar{ArchivePath: path, Gzip: true}
+	if got := readAll(t, fsys, "a.txt"); got != "hello" {
+		t.Errorf("Open(a.txt) = %q, want %q", got, "hello")
+	}
+}
+
+func TestTarOpenMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	writeTestTar(t, path, false)
+
+	if _, err := (Tar{ArchivePath: path}).Open("./a.txt"); err == nil {
+		t.Fatal(`Open("./a.txt") succeeded, want an error since entries are stored without a "./" prefix`)
+	}
+}
+
+func TestZipRootDot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range []struct {
+		name, content string
+	}{
+		{"a.txt", "hello"},
+		{"sub/b.txt", "world"},
+	} {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := Zip{ArchivePath: path}
+
+	if got := readAll(t, fsys, "a.txt"); got != "hello" {
+		t.Errorf("Open(a.txt) = %q, want %q", got, "hello")
+	}
+	if got := readAll(t, fsys, "sub/b.txt"); got != "world" {
+		t.Errorf("Open(sub/b.txt) = %q, want %q", got, "world")
+	}
+
+	info, err := fsys.Lstat("a.txt")
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("Lstat(a.txt).Size() = %d, want 5", info.Size())
+	}
+
+	paths := collect(t, fsys, ".")
+	sort.Strings(paths)
+	want := []string{"a.txt", "sub/b.txt"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("Walk(%q) visited %v, want %v", ".", paths, want)
+	}
+}
+
+func TestZipOpenMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (Zip{ArchivePath: path}).Open("missing.txt"); err == nil {
+		t.Fatal("Open(missing.txt) succeeded, want an error")
+	}
+}
+
+// newMemWebDAVServer starts an in-memory WebDAV server (golang.org/x/net's
+// webdav.MemFS) seeded with the given files, so WebDAV can be exercised
+// without real network infrastructure.
+func newMemWebDAVServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	mfs := webdav.NewMemFS()
+	ctx := context.Background()
+	for name, content := range files {
+		dir := filepath.Dir(name)
+		if dir != "." {
+			if err := mfs.Mkdir(ctx, dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f, err := mfs.OpenFile(ctx, name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &webdav.Handler{FileSystem: mfs, LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebDAV(t *testing.T) {
+	srv := newMemWebDAVServer(t, map[string]string{
+		"a.txt":     "hello",
+		"sub/b.txt": "world",
+	})
+
+	fsys := NewWebDAV(srv.URL)
+
+	if got := readAll(t, fsys, "/a.txt"); got != "hello" {
+		t.Errorf("Open(/a.txt) = %q, want %q", got, "hello")
+	}
+	if got := readAll(t, fsys, "/sub/b.txt"); got != "world" {
+		t.Errorf("Open(/sub/b.txt) = %q, want %q", got, "world")
+	}
+
+	info, err := fsys.Lstat("/a.txt")
+	if err != nil {
+		t.Fatalf("Lstat: %v", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("Lstat(/a.txt).Size() = %d, want 5", info.Size())
+	}
+
+	paths := collect(t, fsys, "/")
+	var names []string
+	for _, p := range paths {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	foundA, foundB := false, false
+	for _, p := range names {
+		if p == "/a.txt" {
+			foundA = true
+		}
+		if p == "/sub/b.txt" {
+			foundB = true
+		}
+	}
+	if !foundA || !foundB {
+		t.Errorf("Walk(/) visited %v, want entries for /a.txt and /sub/b.txt", names)
+	}
+}
@@ -0,0 +1,32 @@
+package fs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local is a Filesystem backed by the machine's local disk.
+type Local struct{}
+
+// Open implements Filesystem.
+func (Local) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// Lstat implements Filesystem.
+func (Local) Lstat(path string) (os.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+// Walk implements Filesystem using filepath.Walk, checking ctx between
+// every entry so a long walk over a huge tree can be cancelled promptly.
+func (Local) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fn(path, info, err)
+	})
+}
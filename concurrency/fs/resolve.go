@@ -0,0 +1,32 @@
+package fs
+
+import "strings"
+
+// Resolve inspects target (as given on the command line) and returns the
+// Filesystem it should be read through together with the root path to walk
+// within that filesystem.
+//
+// Recognised forms:
+//
+//	file:///path/to/dir      -> Local, "/path/to/dir"
+//	dav://host/share         -> WebDAV, "/"
+//	/path/to/archive.tar     -> Tar, "."
+//	/path/to/archive.tar.gz  -> Tar (gzip), "."
+//	/path/to/archive.zip     -> Zip, "."
+//	anything else            -> Local, target
+func Resolve(target string) (Filesystem, string) {
+	switch {
+	case strings.HasPrefix(target, "file://"):
+		return Local{}, strings.TrimPrefix(target, "file://")
+	case strings.HasPrefix(target, "dav://"):
+		return NewWebDAV("https://" + strings.TrimPrefix(target, "dav://")), "/"
+	case strings.HasSuffix(target, ".tar.gz") || strings.HasSuffix(target, ".tgz"):
+		return Tar{ArchivePath: target, Gzip: true}, "."
+	case strings.HasSuffix(target, ".tar"):
+		return Tar{ArchivePath: target}, "."
+	case strings.HasSuffix(target, ".zip"):
+		return Zip{ArchivePath: target}, "."
+	default:
+		return Local{}, target
+	}
+}
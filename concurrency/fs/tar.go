@@ -0,0 +1,132 @@
+package fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Tar is a Filesystem that treats a single .tar or .tar.gz file as a tree,
+// with entry names inside the archive acting as paths. Tar streams cannot be
+// seeked, so Open re-reads the archive from the start until it finds the
+// requested entry.
+type Tar struct {
+	// ArchivePath is the path to the .tar or .tar.gz file on local disk.
+	ArchivePath string
+	// Gzip selects the compress/gzip layer; set it for .tar.gz/.tgz files.
+	Gzip bool
+}
+
+func (t Tar) reader() (io.ReadCloser, *tar.Reader, error) {
+	f, err := os.Open(t.ArchivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !t.Gzip {
+		return f, tar.NewReader(f), nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return gzReadCloser{gz, f}, tar.NewReader(gz), nil
+}
+
+// gzReadCloser closes both the gzip layer and the underlying file.
+type gzReadCloser struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g gzReadCloser) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// Open implements Filesystem by scanning the archive for path and reading
+// its content fully into memory.
+func (t Tar) Open(path string) (io.ReadCloser, error) {
+	rc, tr, err := t.reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("fs: %q not found in %s", path, t.ArchivePath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSuffix(hdr.Name, "/") != path {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// Lstat implements Filesystem by scanning the archive for a matching entry
+// header. Tar headers already describe symlinks without following them.
+func (t Tar) Lstat(path string) (os.FileInfo, error) {
+	rc, tr, err := t.reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSuffix(hdr.Name, "/") == path {
+			return hdr.FileInfo(), nil
+		}
+	}
+}
+
+// Walk implements Filesystem by streaming through the archive once and
+// calling fn for every header, checking ctx between entries.
+func (t Tar) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	rc, tr, err := t.reader()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if root != "." && !strings.HasPrefix(name, root) {
+			continue
+		}
+		if err := fn(name, hdr.FileInfo(), nil); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package fs
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Zip is a Filesystem backed by a single .zip file, using its central
+// directory for direct, non-sequential access to entries.
+type Zip struct {
+	ArchivePath string
+}
+
+func (z Zip) open() (*zip.ReadCloser, error) {
+	return zip.OpenReader(z.ArchivePath)
+}
+
+func (z Zip) find(r *zip.ReadCloser, path string) (*zip.File, error) {
+	for _, f := range r.File {
+		if strings.TrimSuffix(f.Name, "/") == path {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("fs: %q not found in %s", path, z.ArchivePath)
+}
+
+// Open implements Filesystem.
+func (z Zip) Open(path string) (io.ReadCloser, error) {
+	r, err := z.open()
+	if err != nil {
+		return nil, err
+	}
+	f, err := z.find(r, path)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return zipEntry{rc, r}, nil
+}
+
+// zipEntry closes the entry reader and the archive it came from together.
+type zipEntry struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (z zipEntry) Close() error {
+	z.ReadCloser.Close()
+	return z.archive.Close()
+}
+
+// Lstat implements Filesystem.
+func (z Zip) Lstat(path string) (os.FileInfo, error) {
+	r, err := z.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := z.find(r, path)
+	if err != nil {
+		return nil, err
+	}
+	return f.FileInfo(), nil
+}
+
+// Walk implements Filesystem by iterating the archive's central directory,
+// checking ctx between entries.
+func (z Zip) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	r, err := z.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(f.Name, "/")
+		if root != "." && !strings.HasPrefix(name, root) {
+			continue
+		}
+		if err := fn(name, f.FileInfo(), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
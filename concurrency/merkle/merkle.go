@@ -0,0 +1,303 @@
+// Package merkle computes content-addressable Merkle digests of directory
+// trees, with a persistent cache keyed by filesystem and path so that repeated
+// digests of a mostly-unchanged tree don't re-read every file.
+package merkle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+)
+
+// dirEntry is a single path discovered while walking a directory argument,
+// relative to the root that was walked.
+type dirEntry struct {
+	relPath string
+	info    os.FileInfo
+}
+
+// walkAll flattens fsys.Walk(root) into the list of entries beneath it.
+func walkAll(ctx context.Context, fsys fs.Filesystem, root string) ([]dirEntry, error) {
+	var entries []dirEntry
+	err := fsys.Walk(ctx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Printf("ERROR: unable to access %q\n", path)
+			return nil
+		}
+		rel := strings.TrimPrefix(path, root)
+		rel = strings.TrimPrefix(rel, "/")
+		entries = append(entries, dirEntry{relPath: rel, info: info})
+		return nil
+	})
+	return entries, err
+}
+
+// treeNode is one entry of the directory tree rebuilt from a flat walk, with
+// children populated only for directories.
+type treeNode struct {
+	name     string
+	info     os.FileInfo
+	children map[string]*treeNode
+}
+
+// buildTree turns the flat list produced by walkAll back into a directory
+// tree so digests can be folded bottom-up.
+func buildTree(entries []dirEntry) *treeNode {
+	root := &treeNode{children: map[string]*treeNode{}}
+	for _, e := range entries {
+		if e.relPath == "" {
+			root.info = e.info
+			continue
+		}
+		cur := root
+		parts := strings.Split(e.relPath, "/")
+		for i, part := range parts {
+			child, ok := cur.children[part]
+			if !ok {
+				child = &treeNode{name: part, children: map[string]*treeNode{}}
+				cur.children[part] = child
+			}
+			if i == len(parts)-1 {
+				child.info = e.info
+			}
+			cur = child
+		}
+	}
+	return root
+}
+
+// joinPath joins a directory entry onto its parent the way fs.Filesystem
+// paths are expected to look, treating "." (what fs.Resolve returns as the
+// root for Tar/Zip archives) as the empty prefix rather than literally
+// prepending it - Tar.Open/Zip.Open match entries against their raw header
+// name (e.g. "file.txt"), never "./file.txt".
+func joinPath(parent, name string) string {
+	if parent == "" || parent == "." {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// Digest computes the Merkle header and content digests of the directory
+// tree rooted at root, reusing unchanged files' digests from cache.
+//
+//   - regular file:  content = sha256(file bytes)
+//   - directory:     content = sha256(sorted "mode\tname\tchildDigest\n" lines),
+//     directory names in the listing are suffixed with "/"
+//   - symlink:        content = sha256("link:" + target)
+//   - header (every entry): sha256(mode + name), independent of content, so
+//     that a subtree move can be told apart from a content change.
+func Digest(ctx context.Context, fsys fs.Filesystem, root string, cache *Cache) (header, content []byte, err error) {
+	entries, err := walkAll(ctx, fsys, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return digest(fsys, root, buildTree(entries), cache)
+}
+
+func digest(fsys fs.Filesystem, path string, n *treeNode, cache *Cache) (header, content []byte, err error) {
+	var mode os.FileMode
+	if n.info != nil {
+		mode = n.info.Mode()
+	}
+
+	switch {
+	case mode&os.ModeSymlink != 0:
+		target, lerr := readlink(fsys, path)
+		if lerr != nil {
+			return nil, nil, lerr
+		}
+		sum := sha256.Sum256([]byte("link:" + target))
+		content = sum[:]
+
+	case n.info == nil || n.info.IsDir():
+		names := make([]string, 0, len(n.children))
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var buf bytes.Buffer
+		for _, name := range names {
+			child := n.children[name]
+			childPath := joinPath(path, name)
+			_, childContent, cerr := digest(fsys, childPath, child, cache)
+			if cerr != nil {
+				return nil, nil, cerr
+			}
+			childMode := os.FileMode(0)
+			childName := name
+			if child.info != nil {
+				childMode = child.info.Mode()
+				if child.info.IsDir() {
+					childName += "/"
+				}
+			}
+			fmt.Fprintf(&buf, "%s\t%s\t%x\n", childMode, childName, childContent)
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		content = sum[:]
+
+	default:
+		if cached, ok := cache.Lookup(fsys, path, n.info); ok {
+			content = cached.Content
+		} else {
+			data, rerr := readAll(fsys, path)
+			if rerr != nil {
+				return nil, nil, rerr
+			}
+			sum := sha256.Sum256(data)
+			content = sum[:]
+		}
+	}
+
+	headerSum := sha256.Sum256([]byte(fmt.Sprintf("%s\t%s", mode, n.name)))
+	header = headerSum[:]
+
+	if n.info != nil {
+		cache.Store(fsys, path, n.info, header, content)
+	}
+	return header, content, nil
+}
+
+// readAll reads the full content of the regular file at path through fsys.
+func readAll(fsys fs.Filesystem, path string) ([]byte, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// readlink resolves a symlink's target. Only the local disk Filesystem
+// exposes symlinks today; other backends report an error.
+func readlink(fsys fs.Filesystem, path string) (string, error) {
+	if _, ok := fsys.(fs.Local); !ok {
+		return "", fmt.Errorf("fs: symlinks are not supported on this filesystem: %q", path)
+	}
+	return os.Readlink(path)
+}
+
+// CacheEntry is what Cache stores per path: the digests computed last time,
+// tagged with the file metadata they were computed from so a later lookup
+// can tell whether they are still valid.
+type CacheEntry struct {
+	Header  []byte
+	Content []byte
+	ModTime time.Time
+	Size    int64
+}
+
+// Cache is a radix-trie-keyed cache of directory and file digests, keyed by
+// filesystem identity plus path (see cacheKey), so that repeated digests
+// only rehash the files whose mtime or size actually changed, and two
+// unrelated trees that happen to share a path never alias each other.
+type Cache struct {
+	path string
+	trie *radixTrie
+}
+
+// NewCache returns an empty, unpersisted cache, for callers (like watch)
+// that only want to reuse digests within a single process lifetime.
+func NewCache() *Cache {
+	return &Cache{trie: newRadixTrie()}
+}
+
+// LoadCache loads a previously persisted cache from path, or starts empty if
+// it doesn't exist yet.
+func LoadCache(path string) *Cache {
+	c := &Cache{path: path, trie: newRadixTrie()}
+	f, err := os.Open(path)
+	if err != nil {
+		return c
+	}
+	defer f.Close()
+
+	var flat map[string]CacheEntry
+	if err := gob.NewDecoder(f).Decode(&flat); err != nil {
+		return c
+	}
+	for key, entry := range flat {
+		entry := entry
+		c.trie.Put(key, &entry)
+	}
+	return c
+}
+
+// Lookup returns the cached entry for path (read through fsys) if its size
+// and mtime still match info.
+func (c *Cache) Lookup(fsys fs.Filesystem, path string, info os.FileInfo) (*CacheEntry, bool) {
+	entry, ok := c.trie.Get(cacheKey(fsys, path))
+	if !ok {
+		return nil, false
+	}
+	if entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Store records the digests computed for path (read through fsys).
+func (c *Cache) Store(fsys fs.Filesystem, path string, info os.FileInfo, header, content []byte) {
+	c.trie.Put(cacheKey(fsys, path), &CacheEntry{
+		Header:  header,
+		Content: content,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	})
+}
+
+// Save flattens the trie back out and persists it to the path Cache was
+// loaded from. It is a no-op for caches created with NewCache.
+func (c *Cache) Save() error {
+	if c.path == "" {
+		return nil
+	}
+	flat := map[string]CacheEntry{}
+	c.trie.root.collect("", flat)
+
+	f, err := os.Create(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gob.NewEncoder(f).Encode(flat)
+}
+
+// cacheKey qualifies path with the identity of the filesystem it was read
+// through before it touches the trie, so two trees that happen to share a
+// path never collide in the cache - whether that's two local directories
+// walked from different CWDs (path is root-qualified via filepath.Abs), or
+// two different archives/shares whose in-archive or remote paths aren't OS
+// paths at all (an archive/host prefix is used instead, since filepath.Abs
+// would just resolve them against the process CWD and discard which
+// archive or host they actually came from).
+func cacheKey(fsys fs.Filesystem, path string) string {
+	switch v := fsys.(type) {
+	case fs.Local:
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			abs = filepath.Clean(path)
+		}
+		return "local:" + abs
+	case fs.Tar:
+		return fmt.Sprintf("tar:%s:%v:%s", v.ArchivePath, v.Gzip, path)
+	case fs.Zip:
+		return fmt.Sprintf("zip:%s:%s", v.ArchivePath, path)
+	case *fs.WebDAV:
+		return fmt.Sprintf("webdav:%s:%s", v.URI, path)
+	default:
+		return fmt.Sprintf("%T:%s", fsys, path)
+	}
+}
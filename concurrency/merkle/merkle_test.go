@@ -0,0 +1,135 @@
+package merkle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ip1996/golang-elte-2020-public/concurrency/fs"
+)
+
+func writeTestTar(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "archive.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	defer tw.Close()
+	for name, content := range map[string]string{"src/a.txt": "hello", "src/b.txt": "world"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func writeTestZip(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+	for name, content := range map[string]string{"src/a.txt": "hello", "src/b.txt": "world"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// TestDigestArchives exercises the root="." case that fs.Resolve always
+// hands out for Tar/Zip archives: without joinPath normalizing it, the
+// recursive path join produces "./<name>", which Tar.Open/Zip.Open never
+// match against their raw header names.
+func TestDigestArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	tarPath := writeTestTar(t, dir)
+	zipPath := writeTestZip(t, dir)
+
+	tests := []struct {
+		name string
+		fsys fs.Filesystem
+	}{
+		{"tar", fs.Tar{ArchivePath: tarPath}},
+		{"zip", fs.Zip{ArchivePath: zipPath}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, content, err := Digest(context.Background(), tc.fsys, ".", NewCache())
+			if err != nil {
+				t.Fatalf("Digest(%s): %v", tc.name, err)
+			}
+			if len(content) == 0 {
+				t.Fatalf("Digest(%s) returned an empty digest", tc.name)
+			}
+		})
+	}
+}
+
+// TestCacheKeyDistinguishesArchives guards the cache against aliasing: two
+// different archives that happen to contain a same-named, same-size file
+// must not serve each other's cached digest.
+func TestCacheKeyDistinguishesArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	tarPath := filepath.Join(dir, "a.tar")
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	if err := tw.WriteHeader(&tar.Header{Name: "f.txt", Size: 5, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte("aaaaa"))
+	tw.Close()
+	f.Close()
+
+	tarPath2 := filepath.Join(dir, "b.tar")
+	f2, err := os.Create(tarPath2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw2 := tar.NewWriter(f2)
+	if err := tw2.WriteHeader(&tar.Header{Name: "f.txt", Size: 5, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	tw2.Write([]byte("bbbbb"))
+	tw2.Close()
+	f2.Close()
+
+	cache := NewCache()
+	_, c1, err := Digest(context.Background(), fs.Tar{ArchivePath: tarPath}, ".", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, c2, err := Digest(context.Background(), fs.Tar{ArchivePath: tarPath2}, ".", cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(c1, c2) {
+		t.Fatal("two archives with different content at the same in-archive path produced the same digest via the shared cache")
+	}
+}
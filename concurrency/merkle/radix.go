@@ -0,0 +1,98 @@
+package merkle
+
+// radixNode is one node of a PATRICIA-style radix trie: an edge labelled
+// with the byte string it consumes, fanning out by the first byte of
+// whatever remains of the key.
+type radixNode struct {
+	prefix   string
+	value    *CacheEntry
+	children map[byte]*radixNode
+}
+
+// radixTrie is a radix-trie-keyed cache, used here to index CacheEntry
+// values by the keys cacheKey builds (a filesystem identity prefix plus path).
+type radixTrie struct {
+	root *radixNode
+}
+
+func newRadixTrie() *radixTrie {
+	return &radixTrie{root: &radixNode{children: map[byte]*radixNode{}}}
+}
+
+// Get looks up the entry stored under key, if any.
+func (t *radixTrie) Get(key string) (*CacheEntry, bool) {
+	return t.root.get(key)
+}
+
+func (n *radixNode) get(key string) (*CacheEntry, bool) {
+	if key == "" {
+		if n.value != nil {
+			return n.value, true
+		}
+		return nil, false
+	}
+	child, ok := n.children[key[0]]
+	if !ok || !hasPrefix(key, child.prefix) {
+		return nil, false
+	}
+	return child.get(key[len(child.prefix):])
+}
+
+// Put stores v under key, splitting edges as necessary to keep the trie
+// prefix-compressed.
+func (t *radixTrie) Put(key string, v *CacheEntry) {
+	t.root.put(key, v)
+}
+
+func (n *radixNode) put(key string, v *CacheEntry) {
+	if key == "" {
+		n.value = v
+		return
+	}
+	child, ok := n.children[key[0]]
+	if !ok {
+		n.children[key[0]] = &radixNode{prefix: key, value: v, children: map[byte]*radixNode{}}
+		return
+	}
+
+	common := commonPrefixLen(child.prefix, key)
+	if common == len(child.prefix) {
+		child.put(key[common:], v)
+		return
+	}
+
+	// The new key diverges partway through the existing edge: split it.
+	split := &radixNode{prefix: child.prefix[:common], children: map[byte]*radixNode{}}
+	child.prefix = child.prefix[common:]
+	split.children[child.prefix[0]] = child
+	n.children[key[0]] = split
+	split.put(key[common:], v)
+}
+
+// collect walks the trie depth-first, appending every stored entry to out
+// under its full reconstructed key.
+func (n *radixNode) collect(prefix string, out map[string]CacheEntry) {
+	full := prefix + n.prefix
+	if n.value != nil {
+		out[full] = *n.value
+	}
+	for _, child := range n.children {
+		child.collect(full, out)
+	}
+}
+
+func hasPrefix(s, prefix string) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
+}
+
+func commonPrefixLen(a, b string) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,106 @@
+package merkle
+
+import "testing"
+
+func TestRadixTriePutGet(t *testing.T) {
+	entry := func(tag string) *CacheEntry {
+		return &CacheEntry{Content: []byte(tag)}
+	}
+
+	cases := []struct {
+		name string
+		do   func(trie *radixTrie)
+		key  string
+		want string
+		ok   bool
+	}{
+		{
+			name: "single key",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/b/c", entry("c"))
+			},
+			key: "/a/b/c", want: "c", ok: true,
+		},
+		{
+			name: "miss on unrelated key",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/b/c", entry("c"))
+			},
+			key: "/a/b/d", ok: false,
+		},
+		{
+			name: "miss on prefix of a stored key",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/b/c", entry("c"))
+			},
+			key: "/a/b", ok: false,
+		},
+		{
+			name: "shared prefix splits the edge, both keys still resolve",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/bird", entry("bird"))
+				trie.Put("/a/bike", entry("bike"))
+			},
+			key: "/a/bird", want: "bird", ok: true,
+		},
+		{
+			name: "split still resolves the other branch",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/bird", entry("bird"))
+				trie.Put("/a/bike", entry("bike"))
+			},
+			key: "/a/bike", want: "bike", ok: true,
+		},
+		{
+			name: "one key a strict prefix of the other",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/b", entry("short"))
+				trie.Put("/a/bc", entry("long"))
+			},
+			key: "/a/b", want: "short", ok: true,
+		},
+		{
+			name: "overwrite replaces the stored value",
+			do: func(trie *radixTrie) {
+				trie.Put("/a/b", entry("first"))
+				trie.Put("/a/b", entry("second"))
+			},
+			key: "/a/b", want: "second", ok: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			trie := newRadixTrie()
+			c.do(trie)
+			got, ok := trie.Get(c.key)
+			if ok != c.ok {
+				t.Fatalf("Get(%q) ok = %v, want %v", c.key, ok, c.ok)
+			}
+			if ok && string(got.Content) != c.want {
+				t.Fatalf("Get(%q) = %q, want %q", c.key, got.Content, c.want)
+			}
+		})
+	}
+}
+
+func TestRadixTrieCollect(t *testing.T) {
+	trie := newRadixTrie()
+	trie.Put("/a/bird", &CacheEntry{Content: []byte("bird")})
+	trie.Put("/a/bike", &CacheEntry{Content: []byte("bike")})
+	trie.Put("/z", &CacheEntry{Content: []byte("z")})
+
+	out := map[string]CacheEntry{}
+	trie.root.collect("", out)
+
+	want := map[string]string{"/a/bird": "bird", "/a/bike": "bike", "/z": "z"}
+	if len(out) != len(want) {
+		t.Fatalf("collect() returned %d entries, want %d: %v", len(out), len(want), out)
+	}
+	for key, content := range want {
+		entry, ok := out[key]
+		if !ok || string(entry.Content) != content {
+			t.Fatalf("collect()[%q] = %+v, want Content %q", key, entry, content)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package mhash is a small pluggable, pooled set of hash algorithms shared
+// by cksum and watch, so a single io.Copy over a file can feed several
+// algorithms at once through io.MultiWriter instead of re-reading the file
+// once per algorithm.
+package mhash
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Names lists the algorithms accepted by -hash, in the order they're
+// presented in --help.
+var Names = []string{"sha1", "sha256", "sha512", "blake2b", "blake3", "md5", "crc32", "cksum"}
+
+var factories = map[string]func() hash.Hash{
+	"sha1":    sha1.New,
+	"sha256":  sha256.New,
+	"sha512":  sha512.New,
+	"md5":     md5.New,
+	"crc32":   func() hash.Hash { return crc32.NewIEEE() },
+	"cksum":   func() hash.Hash { return newPosixCksum() },
+	"blake2b": newBlake2b,
+	"blake3":  func() hash.Hash { return blake3.New() },
+}
+
+func newBlake2b() hash.Hash {
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		// Only returns an error for a bad MAC key, and we never pass one.
+		panic(err)
+	}
+	return h
+}
+
+// pools holds one sync.Pool of hash.Hash per algorithm name, so hashing a
+// large tree doesn't keep allocating fresh hasher state for every file.
+var pools sync.Map // name -> *sync.Pool
+
+func poolFor(name string) *sync.Pool {
+	if p, ok := pools.Load(name); ok {
+		return p.(*sync.Pool)
+	}
+	newHash := factories[name]
+	p, _ := pools.LoadOrStore(name, &sync.Pool{New: func() interface{} { return newHash() }})
+	return p.(*sync.Pool)
+}
+
+// Valid reports whether name is a known algorithm.
+func Valid(name string) bool {
+	_, ok := factories[name]
+	return ok
+}
+
+// Parse splits a comma-separated -hash flag value ("sha256,blake3") into
+// algorithm names, validating every one of them.
+func Parse(flagValue string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(flagValue, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !Valid(name) {
+			return nil, fmt.Errorf("mhash: unknown hash algorithm %q (want one of %s)", name, strings.Join(Names, ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("mhash: no hash algorithm given")
+	}
+	return names, nil
+}
+
+// Sum reads r once and returns the digest of every requested algorithm,
+// borrowing each hash.Hash from its pool for the duration of the call.
+func Sum(r io.Reader, algos []string) (map[string][]byte, error) {
+	hashers := make(map[string]hash.Hash, len(algos))
+	writers := make([]io.Writer, 0, len(algos))
+	for _, name := range algos {
+		h := poolFor(name).Get().(hash.Hash)
+		h.Reset()
+		hashers[name] = h
+		writers = append(writers, h)
+	}
+	defer func() {
+		for name, h := range hashers {
+			poolFor(name).Put(h)
+		}
+	}()
+
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string][]byte, len(algos))
+	for name, h := range hashers {
+		sums[name] = h.Sum(nil)
+	}
+	return sums, nil
+}
+
+// Equal reports whether two multi-hash results agree on every algorithm
+// present in either of them.
+func Equal(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, av := range a {
+		bv, ok := b[name]
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if av[i] != bv[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
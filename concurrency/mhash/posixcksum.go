@@ -0,0 +1,67 @@
+package mhash
+
+import "encoding/binary"
+
+// crcPoly is the polynomial used by the POSIX cksum(1) algorithm.
+const crcPoly = 0x04C11DB7
+
+// posixCksum implements the POSIX cksum(1) checksum: a non-reflected
+// CRC-32 fed MSB-first over the file bytes, followed by the file's length,
+// with the final register complemented. It satisfies hash.Hash so it can be
+// selected through -hash=cksum like any other algorithm.
+type posixCksum struct {
+	reg uint32
+	n   uint64
+}
+
+func newPosixCksum() *posixCksum { return &posixCksum{} }
+
+// Write implements hash.Hash.
+func (c *posixCksum) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.reg = crcFeed(c.reg, b)
+	}
+	c.n += uint64(len(p))
+	return len(p), nil
+}
+
+// Sum implements hash.Hash. It does not mutate c, so Write may keep being
+// called afterwards, mirroring crypto/sha1 etc. Per POSIX, the length is fed
+// least-significant byte first, stopping at the highest-order non-zero byte
+// (a single zero byte for a zero-length input), not as a fixed-width word.
+func (c *posixCksum) Sum(b []byte) []byte {
+	reg := c.reg
+
+	n := c.n
+	if n == 0 {
+		reg = crcFeed(reg, 0)
+	}
+	for n > 0 {
+		reg = crcFeed(reg, byte(n))
+		n >>= 8
+	}
+	reg = ^reg
+
+	var out [4]byte
+	binary.BigEndian.PutUint32(out[:], reg)
+	return append(b, out[:]...)
+}
+
+func (c *posixCksum) Reset()         { c.reg, c.n = 0, 0 }
+func (c *posixCksum) Size() int      { return 4 }
+func (c *posixCksum) BlockSize() int { return 1 }
+
+// crcFeed runs a single byte through the shift register: the byte is XORed
+// into the top byte of the register, then the register is shifted left
+// eight times, XORing in the polynomial whenever the bit shifted out was 1.
+func crcFeed(reg uint32, b byte) uint32 {
+	reg ^= uint32(b) << 24
+	for i := 0; i < 8; i++ {
+		if reg&0x80000000 != 0 {
+			reg = (reg << 1) ^ crcPoly
+		} else {
+			reg <<= 1
+		}
+	}
+	return reg
+}
@@ -0,0 +1,32 @@
+package mhash
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// Expected values were produced by the system cksum(1) utility (POSIX),
+// to catch any drift from the real algorithm rather than just from itself.
+func TestPosixCksum(t *testing.T) {
+	cases := []struct {
+		input string
+		want  uint32
+	}{
+		{"", 4294967295},
+		{"a", 1220704766},
+		{"hello world", 1135714720},
+		{strings.Repeat("x", 300), 3786917833},
+	}
+
+	for _, c := range cases {
+		h := newPosixCksum()
+		if _, err := h.Write([]byte(c.input)); err != nil {
+			t.Fatalf("Write(%q): %v", c.input, err)
+		}
+		got := binary.BigEndian.Uint32(h.Sum(nil))
+		if got != c.want {
+			t.Errorf("cksum(%q) = %d, want %d", c.input, got, c.want)
+		}
+	}
+}